@@ -6,14 +6,17 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/shabbyrobe/bingen"
 )
 
@@ -25,18 +28,89 @@ type FileSystem interface {
 
 type Config struct {
 	Mode bingen.Mode
+
+	// Codec names the compression codec the file data was generated
+	// with, e.g. "gzip", "deflate", "zstd", "brotli" or "none"/"". See
+	// RegisterCodec.
+	Codec string
+
+	// Gzip is deprecated: it's kept so Config values emitted by older
+	// versions of bingen still work. A true value is equivalent to
+	// Codec: "gzip".
 	Gzip bool
-	Data interface{}
+
+	Data  interface{}
+	ETags map[string]string
+}
+
+// Codec decompresses data that bingen compressed at generate time.
+// NewReader wraps r, which yields the stored (compressed) bytes, in a
+// reader that yields the decompressed bytes.
+type Codec interface {
+	NewReader(r io.Reader) (io.Reader, error)
+}
+
+// codecs holds the registered Codec implementations, keyed by the name
+// a Config's Codec field uses to select them. "gzip" is always
+// available; register others (e.g. zstd, brotli) from an init func via
+// RegisterCodec so binfs itself doesn't have to depend on them.
+var codecs = map[string]Codec{
+	"gzip": gzipCodec{},
+}
+var codecsLock sync.RWMutex
+
+// RegisterCodec makes codec available under name for FileSystems whose
+// Config.Codec field is set to it. Call it from an init func before any
+// affected Config is used.
+func RegisterCodec(name string, codec Codec) {
+	codecsLock.Lock()
+	defer codecsLock.Unlock()
+	codecs[name] = codec
+}
+
+// lookupCodec returns the Codec registered under name, or nil if name
+// is "none"/"" (meaning the data isn't compressed at all).
+func lookupCodec(name string) (Codec, error) {
+	if name == "" || name == "none" {
+		return nil, nil
+	}
+	codecsLock.RLock()
+	defer codecsLock.RUnlock()
+	codec, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("binfs: unknown codec %q; register it with binfs.RegisterCodec", name)
+	}
+	return codec, nil
+}
+
+type gzipCodec struct{}
+
+func (gzipCodec) NewReader(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }
+
+// codecName resolves the effective codec, falling back to the
+// deprecated Gzip field for Config values generated before Codec
+// existed.
+func (c Config) codecName() string {
+	if c.Codec != "" {
+		return c.Codec
+	}
+	if c.Gzip {
+		return "gzip"
+	}
+	return "none"
 }
 
 func (c Config) New() FileSystem {
 	var out FileSystem
 	if c.Mode == bingen.Base64 {
-		out = &stringLazyFileSystem{config: c, data: c.Data.(map[string]string)}
-	} else if c.Gzip {
-		out = &byteLazyFileSystem{config: c, data: c.Data.(map[string][]byte)}
+		data := c.Data.(map[string]string)
+		out = &stringLazyFileSystem{config: c, data: data, loaded: map[string][]byte{}, dirs: buildDirIndex(stringKeys(data))}
+	} else if c.codecName() != "none" {
+		data := c.Data.(map[string][]byte)
+		out = &byteLazyFileSystem{config: c, data: data, loaded: map[string][]byte{}, dirs: buildDirIndex(byteKeys(data))}
 	} else {
-		out = &byteFileSystem{config: c, data: c.Data.(map[string][]byte)}
+		data := c.Data.(map[string][]byte)
+		out = &byteFileSystem{config: c, data: data, dirs: buildDirIndex(byteKeys(data))}
 	}
 	return out
 }
@@ -56,7 +130,13 @@ func (c Config) MustPreload() FileSystem {
 
 // Override a FileSystem with a physical path - use this in
 // development to mask the baked-in FileSystem with updatable
-// versions stored locally.
+// versions stored locally. The result still satisfies FileSystem, so
+// it can be passed to FS() to get an io/fs.FS view with the same
+// dev-mode overrides applied. Internally, path is composed over fs via
+// a real os.DirFS (see DirFS) rather than a bespoke per-file os.Open,
+// so it gets the same path handling os.DirFS gives any other fs.FS
+// consumer. See also Layered and Watch for chaining more than one
+// override or adding live-reload.
 func Override(path string, fs FileSystem) (FileSystem, error) {
 	ofs, ok := fs.(*overrideFileSystem)
 	if ok {
@@ -69,11 +149,12 @@ func Override(path string, fs FileSystem) (FileSystem, error) {
 	if !stat.IsDir() {
 		return nil, fmt.Errorf("binfs: override path %q doesn't exist", path)
 	}
-	return &overrideFileSystem{path, fs}, nil
+	return &overrideFileSystem{path: path, dir: DirFS(path), inner: fs}, nil
 }
 
 type overrideFileSystem struct {
 	path  string
+	dir   FileSystem
 	inner FileSystem
 }
 
@@ -90,20 +171,277 @@ func (fs *overrideFileSystem) Preload() (FileSystem, error) {
 }
 
 func (fs *overrideFileSystem) Open(name string) (http.File, error) {
-	pth := filepath.FromSlash(name)
-	f, err := os.Open(filepath.Join(fs.path, pth))
+	f, err := fs.dir.Open(name)
 	if os.IsNotExist(err) {
 		return fs.inner.Open(name)
-	} else if err == nil {
+	}
+	return f, err
+}
+
+// Layered composes several FileSystems into one, trying each layer in
+// turn on Open and ReadFile - so an earlier layer shadows a later one
+// for the same path - and merging their directory listings the same
+// way. A typical dev-mode chain puts a Watch-wrapped DirFS first and
+// the baked-in production FileSystem last, so edits on disk are picked
+// up without losing the fallback to the compiled-in assets.
+func Layered(layers ...FileSystem) FileSystem {
+	return &layeredFileSystem{layers: layers}
+}
+
+type layeredFileSystem struct {
+	layers []FileSystem
+}
+
+func (fs *layeredFileSystem) ReadFile(name string) ([]byte, error) {
+	return readFile(fs, name)
+}
+
+func (fs *layeredFileSystem) Preload() (FileSystem, error) {
+	preloaded := make([]FileSystem, len(fs.layers))
+	for i, layer := range fs.layers {
+		pl, err := layer.Preload()
+		if err != nil {
+			return nil, err
+		}
+		preloaded[i] = pl
+	}
+	return &layeredFileSystem{layers: preloaded}, nil
+}
+
+func (fs *layeredFileSystem) Open(name string) (http.File, error) {
+	name = cleanDirPath(name)
+
+	var dirs []http.File
+	lastErr := os.ErrNotExist
+
+	for _, layer := range fs.layers {
+		f, err := layer.Open(name)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		stat, err := f.Stat()
+		if err != nil {
+			f.Close()
+			for _, d := range dirs {
+				d.Close()
+			}
+			return nil, err
+		}
+		if !stat.IsDir() {
+			if len(dirs) == 0 {
+				return f, nil
+			}
+			// An earlier layer already resolved name as a directory;
+			// that's the type that wins for shadowing purposes, so this
+			// later file is ignored rather than overriding it.
+			f.Close()
+			continue
+		}
+
+		dirs = append(dirs, f)
+	}
+
+	if len(dirs) > 0 {
+		return mergeDirs(name, dirs)
+	}
+
+	return nil, lastErr
+}
+
+// mergeDirs combines the directory listings of every layer that had
+// name as a directory into one http.File, with entries from earlier
+// layers shadowing later ones of the same name.
+func mergeDirs(name string, dirs []http.File) (http.File, error) {
+	seen := map[string]bool{}
+	var entries []os.FileInfo
+
+	for _, d := range dirs {
+		infos, err := d.Readdir(-1)
+		d.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			if seen[info.Name()] {
+				continue
+			}
+			seen[info.Name()] = true
+			entries = append(entries, info)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return &dirFile{name: name, entries: entries}, nil
+}
+
+// DirFS adapts a plain directory on disk to the FileSystem interface,
+// for use as a layer in Layered (see also Watch) or as a stand-in for
+// baked-in assets in tests.
+func DirFS(path string) FileSystem {
+	return &dirFileSystem{http.FS(os.DirFS(path))}
+}
+
+type dirFileSystem struct {
+	http.FileSystem
+}
+
+func (fs *dirFileSystem) ReadFile(name string) ([]byte, error) {
+	return readFile(fs, name)
+}
+
+func (fs *dirFileSystem) Preload() (FileSystem, error) {
+	return fs, nil
+}
+
+// MemFS returns a FileSystem backed entirely by data held in memory,
+// useful in tests that want a FileSystem without having to run it
+// through bingen first.
+func MemFS(data map[string][]byte) FileSystem {
+	return &byteFileSystem{data: data, dirs: buildDirIndex(byteKeys(data))}
+}
+
+// Watch wraps inner with a cache that's invalidated, file by file, as
+// fsnotify reports changes under path. It's meant to sit in front of a
+// DirFS pointed at the same path, composed with the production
+// FileSystem via Layered, so a long-running process picks up edits to
+// those files on the next request instead of needing a restart.
+func Watch(path string, inner FileSystem) (FileSystem, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := addWatchRecursive(watcher, path); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	fs := &watchFileSystem{
+		path:    path,
+		inner:   inner,
+		watcher: watcher,
+		cache:   map[string][]byte{},
+	}
+	go fs.run()
+	return fs, nil
+}
+
+// addWatchRecursive registers path and every directory beneath it with
+// watcher, since fsnotify only watches the directories it's told about
+// and doesn't descend into new ones on its own.
+func addWatchRecursive(watcher *fsnotify.Watcher, path string) error {
+	return filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return watcher.Add(p)
+		}
+		return nil
+	})
+}
+
+type watchFileSystem struct {
+	path    string
+	inner   FileSystem
+	watcher *fsnotify.Watcher
+
+	lock  sync.Mutex
+	cache map[string][]byte
+}
+
+func (fs *watchFileSystem) run() {
+	for {
+		select {
+		case event, ok := <-fs.watcher.Events:
+			if !ok {
+				return
+			}
+			fs.handleEvent(event)
+
+		case _, ok := <-fs.watcher.Errors:
+			// fsnotify requires both channels to be drained or its
+			// internal dispatch can stall; we've got nowhere better to
+			// surface a watch error from a background goroutine, so
+			// just keep the pump moving.
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+func (fs *watchFileSystem) handleEvent(event fsnotify.Event) {
+	if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) == 0 {
+		return
+	}
+
+	rel, err := filepath.Rel(fs.path, event.Name)
+	if err != nil {
+		return
+	}
+
+	fs.lock.Lock()
+	delete(fs.cache, cleanDirPath(filepath.ToSlash(rel)))
+	fs.lock.Unlock()
+
+	if event.Op&fsnotify.Create != 0 {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			addWatchRecursive(fs.watcher, event.Name)
+		}
+	}
+}
+
+func (fs *watchFileSystem) ReadFile(name string) ([]byte, error) {
+	return readFile(fs, name)
+}
+
+func (fs *watchFileSystem) Preload() (FileSystem, error) {
+	return fs, nil
+}
+
+func (fs *watchFileSystem) Open(name string) (http.File, error) {
+	name = cleanDirPath(name)
+
+	fs.lock.Lock()
+	data, ok := fs.cache[name]
+	fs.lock.Unlock()
+	if ok {
+		return &file{name: name, size: int64(len(data)), rdr: bytes.NewReader(data)}, nil
+	}
+
+	f, err := fs.inner.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if stat.IsDir() {
 		return f, nil
-	} else {
+	}
+	defer f.Close()
+
+	data, err = ioutil.ReadAll(f)
+	if err != nil {
 		return nil, err
 	}
+
+	fs.lock.Lock()
+	fs.cache[name] = data
+	fs.lock.Unlock()
+
+	return &file{name: name, size: int64(len(data)), rdr: bytes.NewReader(data)}, nil
 }
 
 type byteFileSystem struct {
 	config Config
 	data   map[string][]byte
+	dirs   *dirIndex
 }
 
 func (fs *byteFileSystem) ReadFile(name string) ([]byte, error) {
@@ -115,7 +453,11 @@ func (fs *byteFileSystem) Preload() (FileSystem, error) {
 }
 
 func (fs *byteFileSystem) Open(name string) (http.File, error) {
-	name = cleanURLPath(name)
+	name = cleanDirPath(name)
+	if fs.dirs.isDir(name) {
+		return fs.dirs.open(name), nil
+	}
+
 	fileData, ok := fs.data[name]
 	if !ok {
 		return nil, os.ErrNotExist
@@ -132,6 +474,7 @@ type byteLazyFileSystem struct {
 	config Config
 	data   map[string][]byte
 	loaded map[string][]byte
+	dirs   *dirIndex
 	lock   sync.RWMutex
 }
 
@@ -145,31 +488,34 @@ func (fs *byteLazyFileSystem) Preload() (FileSystem, error) {
 
 	var out = make(map[string][]byte, len(fs.data))
 	for name, file := range fs.data {
-		fileData, err := readBytes(file, fs.config.Gzip)
+		fileData, err := readBytes(file, fs.config.codecName())
 		if err != nil {
 			return nil, err
 		}
 		out[name] = fileData
 	}
 
-	return &byteFileSystem{config: fs.config, data: out}, nil
+	return &byteFileSystem{config: fs.config, data: out, dirs: fs.dirs}, nil
 }
 
 func (fs *byteLazyFileSystem) Open(name string) (http.File, error) {
-	name = cleanURLPath(name)
+	name = cleanDirPath(name)
+	if fs.dirs.isDir(name) {
+		return fs.dirs.open(name), nil
+	}
 
 	fs.lock.Lock()
 	defer fs.lock.Unlock()
 
 	fileData, ok := fs.loaded[name]
 	if !ok {
-		var err error
-		decoder, err := gzip.NewReader(bytes.NewReader(fileData))
-		if err != nil {
-			return nil, err
+		raw, ok := fs.data[name]
+		if !ok {
+			return nil, os.ErrNotExist
 		}
 
-		fileData, err = ioutil.ReadAll(decoder)
+		var err error
+		fileData, err = readBytes(raw, fs.config.codecName())
 		if err != nil {
 			return nil, err
 		}
@@ -184,10 +530,34 @@ func (fs *byteLazyFileSystem) Open(name string) (http.File, error) {
 	}, nil
 }
 
+// RawBytes returns the file's bytes exactly as they are stored in the
+// generated map, along with the encoding they are stored under. Callers
+// (such as Handler) can use this to avoid decompressing data that's
+// about to be sent to a client that can handle it compressed.
+func (fs *byteLazyFileSystem) RawBytes(name string) (data []byte, encoding string, ok bool) {
+	codecName := fs.config.codecName()
+	if codecName == "none" {
+		return nil, "", false
+	}
+	data, ok = fs.data[cleanURLPath(name)]
+	if !ok {
+		return nil, "", false
+	}
+	return data, codecName, true
+}
+
+// ETag returns the strong ETag computed at generate time for the named
+// file, if one was emitted.
+func (fs *byteLazyFileSystem) ETag(name string) (string, bool) {
+	etag, ok := fs.config.ETags[cleanURLPath(name)]
+	return etag, ok
+}
+
 type stringLazyFileSystem struct {
 	config Config
 	data   map[string]string
 	loaded map[string][]byte
+	dirs   *dirIndex
 	lock   sync.RWMutex
 }
 
@@ -201,18 +571,21 @@ func (fs *stringLazyFileSystem) Preload() (FileSystem, error) {
 
 	var out = make(map[string][]byte, len(fs.data))
 	for name, fileStr := range fs.data {
-		fileData, err := readString(fileStr, fs.config.Gzip)
+		fileData, err := readString(fileStr, fs.config.codecName())
 		if err != nil {
 			return nil, err
 		}
 		out[name] = fileData
 	}
 
-	return &byteFileSystem{config: fs.config, data: out}, nil
+	return &byteFileSystem{config: fs.config, data: out, dirs: fs.dirs}, nil
 }
 
 func (fs *stringLazyFileSystem) Open(name string) (http.File, error) {
-	name = cleanURLPath(name)
+	name = cleanDirPath(name)
+	if fs.dirs.isDir(name) {
+		return fs.dirs.open(name), nil
+	}
 
 	fs.lock.Lock()
 	defer fs.lock.Unlock()
@@ -225,7 +598,7 @@ func (fs *stringLazyFileSystem) Open(name string) (http.File, error) {
 		}
 
 		var err error
-		fileData, err = readString(fileStr, fs.config.Gzip)
+		fileData, err = readString(fileStr, fs.config.codecName())
 		if err != nil {
 			return nil, err
 		}
@@ -240,6 +613,32 @@ func (fs *stringLazyFileSystem) Open(name string) (http.File, error) {
 	}, nil
 }
 
+// RawBytes returns the file's bytes exactly as they are stored in the
+// generated map (base64-decoded, but not decompressed), along with the
+// encoding they are stored under.
+func (fs *stringLazyFileSystem) RawBytes(name string) (data []byte, encoding string, ok bool) {
+	codecName := fs.config.codecName()
+	if codecName == "none" {
+		return nil, "", false
+	}
+	fileStr, ok := fs.data[cleanURLPath(name)]
+	if !ok {
+		return nil, "", false
+	}
+	raw, err := base64.StdEncoding.DecodeString(fileStr)
+	if err != nil {
+		return nil, "", false
+	}
+	return raw, codecName, true
+}
+
+// ETag returns the strong ETag computed at generate time for the named
+// file, if one was emitted.
+func (fs *stringLazyFileSystem) ETag(name string) (string, bool) {
+	etag, ok := fs.config.ETags[cleanURLPath(name)]
+	return etag, ok
+}
+
 type file struct {
 	name string
 	size int64
@@ -283,13 +682,15 @@ func readFile(fs FileSystem, path string) ([]byte, error) {
 	return ioutil.ReadAll(f)
 }
 
-func readString(fileStr string, withGzip bool) ([]byte, error) {
+func readString(fileStr string, codecName string) ([]byte, error) {
 	var decoder io.Reader = base64.NewDecoder(base64.StdEncoding, strings.NewReader(fileStr))
 
-	var err error
-
-	if withGzip {
-		decoder, err = gzip.NewReader(decoder)
+	codec, err := lookupCodec(codecName)
+	if err != nil {
+		return nil, err
+	}
+	if codec != nil {
+		decoder, err = codec.NewReader(decoder)
 		if err != nil {
 			return nil, err
 		}
@@ -298,17 +699,355 @@ func readString(fileStr string, withGzip bool) ([]byte, error) {
 	return ioutil.ReadAll(decoder)
 }
 
-func readBytes(fileData []byte, withGzip bool) ([]byte, error) {
-	if withGzip {
-		decoder, err := gzip.NewReader(bytes.NewReader(fileData))
-		if err != nil {
-			return nil, err
-		}
-		return ioutil.ReadAll(decoder)
+func readBytes(fileData []byte, codecName string) ([]byte, error) {
+	codec, err := lookupCodec(codecName)
+	if err != nil {
+		return nil, err
+	}
+	if codec == nil {
+		return fileData, nil
 	}
-	return fileData, nil
+	decoder, err := codec.NewReader(bytes.NewReader(fileData))
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(decoder)
 }
 
 func cleanURLPath(str string) string {
 	return strings.TrimLeft(str, "/")
 }
+
+// cleanDirPath is cleanURLPath with the root directory normalised to ".",
+// the name io/fs.FS uses for it.
+func cleanDirPath(str string) string {
+	str = cleanURLPath(str)
+	if str == "" {
+		return "."
+	}
+	return str
+}
+
+func stringKeys(m map[string]string) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
+
+func byteKeys(m map[string][]byte) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
+
+// dirIndex is a synthetic directory tree built once, at FileSystem
+// construction time, from the set of file names in the map. It
+// lets Open("some/dir") return a listable directory even though the
+// underlying data is just a flat map keyed by full path.
+type dirIndex struct {
+	isDirSet map[string]bool
+	children map[string][]string // dir path -> sorted immediate child names (dirs and files)
+}
+
+func buildDirIndex(names []string) *dirIndex {
+	idx := &dirIndex{isDirSet: map[string]bool{".": true}, children: map[string][]string{}}
+
+	addChild := func(dir, child string) {
+		for _, c := range idx.children[dir] {
+			if c == child {
+				return
+			}
+		}
+		idx.children[dir] = append(idx.children[dir], child)
+	}
+
+	for _, name := range names {
+		parts := strings.Split(name, "/")
+		for i := 1; i < len(parts); i++ {
+			dir := strings.Join(parts[:i], "/")
+			parent := "."
+			if i > 1 {
+				parent = strings.Join(parts[:i-1], "/")
+			}
+			idx.isDirSet[dir] = true
+			addChild(parent, parts[i-1])
+		}
+
+		parent := "."
+		if len(parts) > 1 {
+			parent = strings.Join(parts[:len(parts)-1], "/")
+		}
+		addChild(parent, parts[len(parts)-1])
+	}
+
+	for dir := range idx.children {
+		sort.Strings(idx.children[dir])
+	}
+
+	return idx
+}
+
+func (idx *dirIndex) isDir(name string) bool {
+	return idx != nil && idx.isDirSet[name]
+}
+
+func (idx *dirIndex) open(name string) *dirFile {
+	children := idx.children[name]
+	entries := make([]os.FileInfo, len(children))
+	for i, child := range children {
+		full := child
+		if name != "." {
+			full = name + "/" + child
+		}
+		entries[i] = dirEntryInfo{name: child, isDir: idx.isDirSet[full]}
+	}
+	return &dirFile{name: name, entries: entries}
+}
+
+// dirEntryInfo is the os.FileInfo (== fs.FileInfo) for a synthetic
+// directory entry. Baked-in assets carry no real mode or mtime, so the
+// mode is reported as read-only (0444 for files, 0555 for directories)
+// and the mtime as the zero time.
+type dirEntryInfo struct {
+	name  string
+	isDir bool
+}
+
+func (e dirEntryInfo) Name() string { return e.name }
+func (e dirEntryInfo) Size() int64  { return 0 }
+
+func (e dirEntryInfo) Mode() os.FileMode {
+	if e.isDir {
+		return os.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (e dirEntryInfo) ModTime() time.Time { return time.Time{} }
+func (e dirEntryInfo) IsDir() bool        { return e.isDir }
+func (e dirEntryInfo) Sys() interface{}   { return nil }
+
+// dirFile is the http.File (and fs.ReadDirFile-compatible) value
+// returned by Open for a synthetic directory.
+type dirFile struct {
+	name    string
+	entries []os.FileInfo
+	pos     int
+}
+
+func (d *dirFile) Name() string       { return d.name }
+func (d *dirFile) Size() int64        { return 0 }
+func (d *dirFile) Mode() os.FileMode  { return os.ModeDir | 0555 }
+func (d *dirFile) ModTime() time.Time { return time.Time{} }
+func (d *dirFile) IsDir() bool        { return true }
+func (d *dirFile) Sys() interface{}   { return nil }
+func (d *dirFile) Close() error       { return nil }
+func (d *dirFile) Stat() (os.FileInfo, error) { return d, nil }
+
+func (d *dirFile) Read(p []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (d *dirFile) Seek(offset int64, whence int) (int64, error) {
+	return 0, fmt.Errorf("binfs: cannot seek a directory")
+}
+
+func (d *dirFile) Readdir(count int) ([]os.FileInfo, error) {
+	if count <= 0 {
+		out := d.entries[d.pos:]
+		d.pos = len(d.entries)
+		return out, nil
+	}
+	if d.pos >= len(d.entries) {
+		return nil, io.EOF
+	}
+	end := d.pos + count
+	if end > len(d.entries) {
+		end = len(d.entries)
+	}
+	out := d.entries[d.pos:end]
+	d.pos = end
+	return out, nil
+}
+
+func (d *dirFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	infos, err := d.Readdir(count)
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	return entries, err
+}
+
+// FS adapts fsys to the standard io/fs.FS surface (and, via
+// fs.ReadFileFS, fs.ReadDirFS, fs.StatFS and fs.GlobFS, the extended
+// ones too), for use anywhere an fs.FS is expected, such as
+// html/template.ParseFS, text/template.ParseFS or http.FS.
+func FS(fsys FileSystem) fs.FS {
+	return &fsAdapter{fsys}
+}
+
+type fsAdapter struct {
+	fsys FileSystem
+}
+
+func (a *fsAdapter) Open(name string) (fs.File, error) {
+	f, err := a.fsys.Open(name)
+	if err != nil {
+		return nil, toPathError("open", name, err)
+	}
+	return f, nil
+}
+
+func (a *fsAdapter) ReadFile(name string) ([]byte, error) {
+	data, err := a.fsys.ReadFile(name)
+	if err != nil {
+		return nil, toPathError("readfile", name, err)
+	}
+	return data, nil
+}
+
+func (a *fsAdapter) ReadDir(name string) ([]fs.DirEntry, error) {
+	f, err := a.fsys.Open(name)
+	if err != nil {
+		return nil, toPathError("readdir", name, err)
+	}
+	defer f.Close()
+
+	infos, err := f.Readdir(-1)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	return entries, nil
+}
+
+func (a *fsAdapter) Stat(name string) (fs.FileInfo, error) {
+	f, err := a.fsys.Open(name)
+	if err != nil {
+		return nil, toPathError("stat", name, err)
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+func (a *fsAdapter) Glob(pattern string) ([]string, error) {
+	// Hide the GlobFS method from fs.Glob, which would otherwise call
+	// straight back into this one.
+	return fs.Glob(struct{ fs.ReadDirFS }{a}, pattern)
+}
+
+func toPathError(op, path string, err error) error {
+	if os.IsNotExist(err) {
+		return &fs.PathError{Op: op, Path: path, Err: fs.ErrNotExist}
+	}
+	return &fs.PathError{Op: op, Path: path, Err: err}
+}
+
+// rawByteser is implemented by FileSystem implementations that can hand
+// back their stored bytes without decompressing them first.
+type rawByteser interface {
+	RawBytes(name string) (data []byte, encoding string, ok bool)
+}
+
+// etagger is implemented by FileSystem implementations that computed a
+// strong ETag for each file at generate time.
+type etagger interface {
+	ETag(name string) (string, bool)
+}
+
+// Handler returns an http.Handler that serves the contents of fs. If fs
+// was generated with the "gzip" codec and the request advertises
+// "Accept-Encoding: gzip", the compressed bytes stored in the map are
+// written straight to the response with "Content-Encoding: gzip" set,
+// at zero decompression cost. Any other request falls back to
+// fs.Open, which transparently decompresses as needed.
+func Handler(fs FileSystem) http.Handler {
+	return &fsHandler{fs: fs}
+}
+
+type fsHandler struct {
+	fs FileSystem
+}
+
+func (h *fsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := cleanURLPath(r.URL.Path)
+
+	if rb, ok := h.fs.(rawByteser); ok && acceptsGzip(r) {
+		if data, encoding, ok := rb.RawBytes(name); ok && encoding == "gzip" {
+			h.serveRaw(w, r, name, data)
+			return
+		}
+	}
+
+	f, err := h.fs.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	http.ServeContent(w, r, stat.Name(), stat.ModTime(), f)
+}
+
+func (h *fsHandler) serveRaw(w http.ResponseWriter, r *http.Request, name string, data []byte) {
+	if et, ok := h.fs.(etagger); ok {
+		if etag, ok := et.ETag(name); ok {
+			w.Header().Set("ETag", etag)
+			if inm := r.Header.Get("If-None-Match"); inm != "" && inm == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+	}
+
+	if ctype, err := sniffGzipContentType(data); err == nil && ctype != "" {
+		w.Header().Set("Content-Type", ctype)
+	}
+
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Set("Vary", "Accept-Encoding")
+	w.Write(data)
+}
+
+// sniffGzipContentType detects the content type of gzip-compressed data
+// by decompressing a small prefix, without reading the whole payload.
+func sniffGzipContentType(data []byte) (string, error) {
+	gr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	defer gr.Close()
+
+	var prefix [512]byte
+	n, err := io.ReadFull(gr, prefix[:])
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+
+	return http.DetectContentType(prefix[:n]), nil
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		if strings.TrimSpace(enc) == "gzip" {
+			return true
+		}
+	}
+	return false
+}
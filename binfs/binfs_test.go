@@ -0,0 +1,147 @@
+package binfs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shabbyrobe/bingen"
+)
+
+func mustGzip(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestHandlerGzipPassthroughAndFallback covers the two paths ServeHTTP
+// can take for a gzip-compressed FileSystem: serving the already
+// compressed bytes straight through for a client that advertises gzip
+// support, and transparently decompressing for one that doesn't.
+func TestHandlerGzipPassthroughAndFallback(t *testing.T) {
+	plain := []byte("hello world")
+	cfg := Config{
+		Mode:  bingen.Bytes,
+		Codec: "gzip",
+		Data:  map[string][]byte{"hello.txt": mustGzip(t, plain)},
+	}
+	handler := Handler(cfg.New())
+
+	t.Run("gzip client gets the compressed bytes untouched", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/hello.txt", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+			t.Fatalf("Content-Encoding = %q, want %q", got, "gzip")
+		}
+
+		gr, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatalf("response body isn't valid gzip: %v", err)
+		}
+		got, err := ioutil.ReadAll(gr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Fatalf("decompressed body = %q, want %q", got, plain)
+		}
+	})
+
+	t.Run("non-gzip client gets decompressed bytes", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/hello.txt", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Content-Encoding"); got != "" {
+			t.Fatalf("Content-Encoding = %q, want none", got)
+		}
+		if got := rec.Body.Bytes(); !bytes.Equal(got, plain) {
+			t.Fatalf("body = %q, want %q", got, plain)
+		}
+	})
+}
+
+// TestLayered checks that an earlier layer shadows a later one for a
+// path both define, while paths unique to a single layer still resolve.
+func TestLayered(t *testing.T) {
+	top := MemFS(map[string][]byte{
+		"only-top.txt": []byte("top"),
+		"shared.txt":   []byte("from top"),
+	})
+	bottom := MemFS(map[string][]byte{
+		"only-bottom.txt": []byte("bottom"),
+		"shared.txt":      []byte("from bottom"),
+	})
+
+	fsys := Layered(top, bottom)
+
+	if data, err := fsys.ReadFile("shared.txt"); err != nil || string(data) != "from top" {
+		t.Fatalf("ReadFile(shared.txt) = %q, %v; want %q, nil", data, err, "from top")
+	}
+	if data, err := fsys.ReadFile("only-top.txt"); err != nil || string(data) != "top" {
+		t.Fatalf("ReadFile(only-top.txt) = %q, %v; want %q, nil", data, err, "top")
+	}
+	if data, err := fsys.ReadFile("only-bottom.txt"); err != nil || string(data) != "bottom" {
+		t.Fatalf("ReadFile(only-bottom.txt) = %q, %v; want %q, nil", data, err, "bottom")
+	}
+
+	if _, err := fsys.ReadFile("missing.txt"); !os.IsNotExist(err) {
+		t.Fatalf("ReadFile(missing.txt) err = %v, want os.IsNotExist", err)
+	}
+}
+
+// TestWatchPicksUpChanges confirms a file edited on disk after Watch
+// starts is eventually served back with its new content, rather than
+// whatever was cached when Watch was called.
+func TestWatchPicksUpChanges(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := ioutil.WriteFile(path, []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := Watch(dir, DirFS(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := fsys.ReadFile("a.txt"); err != nil || string(data) != "v1" {
+		t.Fatalf("ReadFile(a.txt) = %q, %v; want %q, nil", data, err, "v1")
+	}
+
+	if err := ioutil.WriteFile(path, []byte("v2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// fsnotify delivers the write asynchronously, so poll for the cache
+	// to pick it up instead of asserting immediately.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		data, err := fsys.ReadFile("a.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) == "v2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Watch never observed the write; last read %q", data)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -1,20 +1,34 @@
 package bingen
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"bytes"
+	"compress/bzip2"
+	"compress/flate"
 	"compress/gzip"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"go/format"
+	"hash/crc32"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"text/template"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
 )
 
 const Usage = `binmap: generate a golang map from binary files
@@ -28,13 +42,23 @@ Each entry can be prefixed with an alias which determines its output path in
 the resulting map, for example 'foo:path/to/stuff' would make
 'path/to/stuff/thing.jpg' available at 'foo/thing.jpg'
 
+An <input> may also point at a .zip, .tar, .tar.gz/.tgz or .tar.bz2/.tbz2
+archive, in which case its contents are unpacked in memory and added to the
+map as though each entry were a file on disk - the alias/strip prefix applies
+to the path inside the archive.
+
 Compression:
 
 If you are packing a huge amount of stuff into your map, it might get quite
 large. In this case, it can be beneficial to compress the file data in the code
-and unpack it all either on startup or as needed. Pass '-gzip=<level>' to 
-enable compression. The compression is indiscriminate - it applies to all files
-even if they're not very compressible (like jpegs).
+and unpack it all either on startup or as needed. Pass '-codec=<name>[:level]'
+to pick a compression codec: 'none', 'gzip', 'deflate', 'zstd' or 'brotli',
+optionally followed by a ':<level>' to control the compression/speed
+trade-off. The compression is indiscriminate - it applies to all files even if
+they're not very compressible (like jpegs).
+
+'-gzip=<level>' is kept as a deprecated alias for '-codec=gzip:<level>'
+('-gzip=0' maps to '-codec=none').
 
 Output formats:
 
@@ -42,6 +66,19 @@ Files are output as C-style byte arrays by default, which is very fast at build
 time, start time and run time but the resulting go file is massive if you have
 a lot of statics. Use '-b64' to emit the files as base64-encoded strings instead.
 The output is hideous, but the output isn't really meant for humans to read.
+
+Pass '-fsvar=<Name>' to also emit a variable of that name holding an io/fs.FS
+view of the map, for use with html/template.ParseFS, text/template.ParseFS
+or http.FS.
+
+Performance:
+
+Reading, compressing and encoding files are all done by a pool of '-jobs=N'
+workers (default: the number of logical CPUs) instead of one file at a time,
+which matters once the input tree runs into the thousands of files or tens of
+megabytes. With the 'gzip' codec, files larger than 6MiB are additionally
+split into ~1MiB blocks that are gzip-compressed in parallel and concatenated,
+so no single huge file can stall the rest of the pool.
 `
 
 type usageError string
@@ -75,10 +112,13 @@ type Command struct {
 	out    string
 	pkg    string
 	name   string
+	fsvar  string
 	mode   Mode
 	nofmt  bool
 	rawMap bool
+	codec  string
 	gzip   int
+	jobs   int
 	ignore stringList
 	tags   string
 }
@@ -87,11 +127,14 @@ func (m *Command) Flags(fs *flag.FlagSet) {
 	fs.StringVar(&m.out, "out", "", "Output file")
 	fs.StringVar(&m.pkg, "pkg", "", "Output package (uses the GOPACKAGE env var if empty)")
 	fs.StringVar(&m.name, "name", "files", "Output variable name")
+	fs.StringVar(&m.fsvar, "fsvar", "", "If set, also emit a var of this name holding an io/fs.FS view of the map")
 	fs.Var(&m.mode, "mode", "Encode mode (base64, bytes)")
 	fs.StringVar(&m.tags, "tags", "", "Build tags")
 	fs.BoolVar(&m.nofmt, "nofmt", false, "Do not run gofmt after generation")
 	fs.BoolVar(&m.rawMap, "rawmap", false, "Use a raw map instead of a Config")
-	fs.IntVar(&m.gzip, "gzip", 9, "gzip compression level (0 for none)")
+	fs.StringVar(&m.codec, "codec", "gzip:9", "Compression codec and optional level: none, gzip[:level], deflate[:level], zstd[:level], brotli[:level]")
+	fs.IntVar(&m.gzip, "gzip", -1, "Deprecated: use -codec=gzip:<level> instead. gzip compression level (0 for none)")
+	fs.IntVar(&m.jobs, "jobs", runtime.GOMAXPROCS(0), "Number of parallel workers used to read, compress and encode files")
 	fs.Var(&m.ignore, "ignore", "regexp pattern to ignore. Can pass multiple times.")
 }
 
@@ -117,6 +160,11 @@ func (m *Command) Run(args ...string) (rerr error) {
 
 	var buf bytes.Buffer
 
+	jobs := m.jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
 	inputs, err := readInputs(args)
 	if err != nil {
 		return err
@@ -131,13 +179,27 @@ func (m *Command) Run(args ...string) (rerr error) {
 		ignore = append(ignore, ptn)
 	}
 
-	names, files, err := loadFiles(inputs, ignore)
+	names, files, err := loadFiles(inputs, ignore, jobs)
 	if err != nil {
 		return err
 	}
 
-	if m.gzip > 0 {
-		if err := gzipFiles(files, m.gzip); err != nil {
+	codecName, level, err := parseCodec(m.codec)
+	if err != nil {
+		return usageError(fmt.Sprintf("binmap: %v", err))
+	}
+	if m.gzip >= 0 {
+		// Deprecated -gzip flag was passed explicitly; it takes precedence
+		// over -codec for backward compatibility.
+		if m.gzip == 0 {
+			codecName, level = "none", 0
+		} else {
+			codecName, level = "gzip", m.gzip
+		}
+	}
+
+	if codecName != "none" {
+		if err := compressFiles(files, codecName, level, jobs); err != nil {
 			return err
 		}
 	}
@@ -148,9 +210,9 @@ func (m *Command) Run(args ...string) (rerr error) {
 		var err error
 		switch m.mode {
 		case Base64:
-			err = writeFilesAsBase64(&fileData, names, files)
+			err = writeFilesAsBase64(&fileData, names, files, jobs)
 		case Bytes:
-			err = writeFilesAsByteArray(&fileData, names, files)
+			err = writeFilesAsByteArray(&fileData, names, files, jobs)
 		default:
 			err = fmt.Errorf("unknown mode %q", m.mode)
 		}
@@ -159,14 +221,20 @@ func (m *Command) Run(args ...string) (rerr error) {
 		}
 	}
 
+	var etagData bytes.Buffer
+	writeETags(&etagData, names, files)
+
 	// Create source file
 	tpl := template.Must(template.New("").Parse(binMapTpl))
 	err = tpl.Execute(&buf, &binMapVars{
 		Package:  m.pkg,
 		Name:     m.name,
+		FSVar:    m.fsvar,
 		Tags:     m.tags,
 		Map:      strings.TrimSpace(fileData.String()),
-		Deflated: m.gzip != 0,
+		ETags:    strings.TrimSpace(etagData.String()),
+		Codec:    codecName,
+		Deflated: codecName != "none",
 		Mode:     string(m.mode),
 		AsConfig: !m.rawMap,
 	})
@@ -212,65 +280,406 @@ func (m *Command) Run(args ...string) (rerr error) {
 	return nil
 }
 
-func writeFilesAsBase64(into *bytes.Buffer, names []string, files map[string][]byte) error {
-	into.WriteString("map[string]string{\n")
-
-	for _, name := range names {
+func writeFilesAsBase64(into *bytes.Buffer, names []string, files map[string][]byte, jobs int) error {
+	encoded := encodeFilesParallel(names, files, jobs, func(data []byte) string {
 		var buf bytes.Buffer
 		wrt := base64.NewEncoder(base64.StdEncoding, &buf)
-		wrt.Write(files[name])
+		wrt.Write(data)
 		wrt.Close()
-		val := wrap(buf.String(), 100)
-		into.WriteString(fmt.Sprintf("%q: `%s`,\n\n", name, val))
-	}
+		return wrap(buf.String(), 100)
+	})
 
+	into.WriteString("map[string]string{\n")
+	for _, name := range names {
+		into.WriteString(fmt.Sprintf("%q: `%s`,\n\n", name, encoded[name]))
+	}
 	into.WriteString("}\n")
 	return nil
 }
 
-func writeFilesAsByteArray(into *bytes.Buffer, names []string, files map[string][]byte) error {
-	into.WriteString("map[string][]byte{\n")
+func writeFilesAsByteArray(into *bytes.Buffer, names []string, files map[string][]byte, jobs int) error {
+	encoded := encodeFilesParallel(names, files, jobs, func(data []byte) string {
+		var buf bytes.Buffer
+		for i, b := range data {
+			if i > 0 && i%16 == 0 {
+				buf.WriteByte('\n')
+			}
+			fmt.Fprintf(&buf, "0x%02x, ", b)
+		}
+		return buf.String()
+	})
 
+	into.WriteString("map[string][]byte{\n")
 	for _, name := range names {
-		into.WriteString(fmt.Sprintf("%q: []byte{\n", name))
-		for i, b := range files[name] {
-			if i > 0 && i%16 == 0 {
-				into.WriteByte('\n')
+		into.WriteString(fmt.Sprintf("%q: []byte{\n%s},\n", name, encoded[name]))
+	}
+	into.WriteString("}\n")
+	return nil
+}
+
+// encodeFilesParallel renders each named file's data through encode
+// using a pool of jobs workers, and returns the rendered text keyed by
+// name. Callers join the results back together by iterating names, so
+// the generated output stays deterministic regardless of how the
+// workers are scheduled.
+func encodeFilesParallel(names []string, files map[string][]byte, jobs int, encode func([]byte) string) map[string]string {
+	type result struct {
+		name string
+		text string
+	}
+
+	work := make(chan string)
+	results := make(chan result)
+
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range work {
+				results <- result{name: name, text: encode(files[name])}
 			}
-			into.WriteString(fmt.Sprintf("0x%02x, ", b))
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	go func() {
+		defer close(work)
+		for _, name := range names {
+			work <- name
 		}
-		into.WriteString("},\n")
+	}()
+
+	out := make(map[string]string, len(names))
+	for r := range results {
+		out[r.name] = r.text
+	}
+	return out
+}
+
+// writeETags emits a strong ETag for each file, computed from the final
+// bytes stored in the map (compressed with the chosen codec, if any),
+// so that clients
+// reading the raw compressed payload directly (see binfs.Handler) can
+// still validate it against If-None-Match.
+func writeETags(into *bytes.Buffer, names []string, files map[string][]byte) {
+	into.WriteString("map[string]string{\n")
+
+	for _, name := range names {
+		sum := sha256.Sum256(files[name])
+		etag := `"` + hex.EncodeToString(sum[:]) + `"`
+		into.WriteString(fmt.Sprintf("%q: %q,\n", name, etag))
 	}
 
 	into.WriteString("}\n")
+}
+
+// parseCodec splits a "-codec" flag value of the form "<name>[:level]" into
+// its codec name and compression level, defaulting the level if it's
+// omitted.
+func parseCodec(spec string) (name string, level int, err error) {
+	parts := strings.SplitN(spec, ":", 2)
+	name = strings.TrimSpace(parts[0])
+	if name == "" {
+		name = "none"
+	}
+
+	switch name {
+	case "none", "gzip", "deflate", "zstd", "brotli":
+	default:
+		return "", 0, fmt.Errorf("unknown codec %q", name)
+	}
+
+	level = defaultCodecLevel(name)
+	if len(parts) == 2 {
+		level, err = strconv.Atoi(parts[1])
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid level in codec %q: %v", spec, err)
+		}
+	}
+
+	return name, level, nil
+}
+
+func defaultCodecLevel(name string) int {
+	switch name {
+	case "gzip":
+		return gzip.DefaultCompression
+	case "deflate":
+		return flate.DefaultCompression
+	case "zstd":
+		// zstd levels here are the classic 1-22 scale (what
+		// zstd.EncoderLevelFromZstd expects), not a zstd.EncoderLevel
+		// ordinal - 3 is zstd's own default compression level.
+		return 3
+	case "brotli":
+		return brotli.DefaultCompression
+	default:
+		return 0
+	}
+}
+
+// parallelBlockThreshold is the file size above which compressFiles
+// splits a gzip-codec file into blocks and compresses them in parallel
+// instead of handing the whole file to one worker.
+const parallelBlockThreshold = 6 * 1024 * 1024 // 6 MiB
+
+// parallelBlockSize is the size of each block compressGzipParallelBlocks
+// compresses independently.
+const parallelBlockSize = 1024 * 1024 // 1 MiB
+
+// compressFiles compresses every file in-place with codec, spreading
+// the work across a pool of jobs workers. Each worker keeps its own
+// compressor for the life of the pool and resets it between files
+// instead of allocating a fresh one per file.
+func compressFiles(files map[string][]byte, codec string, level int, jobs int) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+
+	type result struct {
+		name       string
+		compressed []byte
+		err        error
+	}
+
+	work := make(chan string)
+	results := make(chan result)
+
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := newReusableCompressor(codec, level)
+			for name := range work {
+				data := files[name]
+
+				var compressed []byte
+				var err error
+				if codec == "gzip" && len(data) > parallelBlockThreshold {
+					compressed, err = compressGzipParallelBlocks(data, level)
+				} else {
+					compressed, err = c.compress(data)
+				}
+				if err != nil {
+					err = fmt.Errorf("binmap: could not compress %s with %s: %v", name, codec, err)
+				}
+				results <- result{name: name, compressed: compressed, err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	go func() {
+		defer close(work)
+		for _, name := range names {
+			work <- name
+		}
+	}()
+
+	// Collect into a separate map rather than writing back into files
+	// as results arrive: workers are still concurrently reading
+	// files[name] above, so writing into files from this goroutine at
+	// the same time is a concurrent map read/write - a fatal error in
+	// Go, not just a race. results only closes once every worker has
+	// returned and so stopped reading files, so merging into files
+	// below, after draining results, is safe.
+	compressed := make(map[string][]byte, len(names))
+	var firstErr error
+	for r := range results {
+		if r.err != nil {
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			continue
+		}
+		compressed[r.name] = r.compressed
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+
+	for name, data := range compressed {
+		files[name] = data
+	}
 	return nil
 }
 
-func gzipFiles(files map[string][]byte, level int) error {
-	for n, data := range files {
-		var buf bytes.Buffer
-		err := func() error {
-			fw, err := gzip.NewWriterLevel(&buf, level)
+// reusableCompressor holds a single compression writer that's reset
+// and reused across files on one worker goroutine, avoiding the
+// per-file writer allocation a fresh gzip.NewWriterLevel (or
+// equivalent) call would otherwise cost.
+type reusableCompressor struct {
+	codec string
+	level int
+	buf   bytes.Buffer
+
+	gzipW  *gzip.Writer
+	flateW *flate.Writer
+	zstdW  *zstd.Encoder
+	brW    *brotli.Writer
+}
+
+func newReusableCompressor(codec string, level int) *reusableCompressor {
+	return &reusableCompressor{codec: codec, level: level}
+}
+
+func (c *reusableCompressor) compress(data []byte) ([]byte, error) {
+	c.buf.Reset()
+
+	var w io.WriteCloser
+	switch c.codec {
+	case "gzip":
+		if c.gzipW == nil {
+			gw, err := gzip.NewWriterLevel(&c.buf, c.level)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			defer func() {
-				if err := fw.Close(); err != nil {
-					panic(err)
-				}
-			}()
-			if _, err := fw.Write(data); err != nil {
-				return err
+			c.gzipW = gw
+		} else {
+			c.gzipW.Reset(&c.buf)
+		}
+		w = c.gzipW
+
+	case "deflate":
+		if c.flateW == nil {
+			fw, err := flate.NewWriter(&c.buf, c.level)
+			if err != nil {
+				return nil, err
 			}
-			return nil
-		}()
+			c.flateW = fw
+		} else {
+			c.flateW.Reset(&c.buf)
+		}
+		w = c.flateW
 
+	case "zstd":
+		if c.zstdW == nil {
+			zw, err := zstd.NewWriter(&c.buf, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(c.level)))
+			if err != nil {
+				return nil, err
+			}
+			c.zstdW = zw
+		} else {
+			c.zstdW.Reset(&c.buf)
+		}
+		w = c.zstdW
+
+	case "brotli":
+		if c.brW == nil {
+			c.brW = brotli.NewWriterLevel(&c.buf, c.level)
+		} else {
+			c.brW.Reset(&c.buf)
+		}
+		w = c.brW
+
+	default:
+		return nil, fmt.Errorf("unknown codec %q", c.codec)
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, c.buf.Len())
+	copy(out, c.buf.Bytes())
+	return out, nil
+}
+
+// compressGzipParallelBlocks compresses a large file faster by
+// splitting it into ~1 MiB blocks and gzip-compressing each block
+// independently on its own goroutine.
+//
+// Each block is written out as its own complete, self-contained gzip
+// member (header, deflate body and CRC32/ISIZE footer) rather than a
+// bare concatenated deflate stream: a deflate stream's final block
+// isn't something you can pick up again from a different Writer, but
+// compress/gzip's Reader transparently reads through concatenated
+// members (see gzip.Reader.Multistream), so stitching whole members
+// together back-to-back decodes correctly and still parallelises the
+// expensive part of the work.
+//
+// Blocks do NOT share a dictionary across the split points: gzip, unlike
+// zlib, has no preset-dictionary mechanism, so priming one block's
+// encoder with another block's bytes produces back-references a plain
+// gzip.Reader can't resolve (it starts each member with an empty
+// window) and silently corrupts the output. The cost is a little lost
+// compression ratio right at each ~1 MiB boundary, which is a much
+// better trade than shipping embedded data that fails to decompress at
+// runtime.
+func compressGzipParallelBlocks(data []byte, level int) ([]byte, error) {
+	blockCount := (len(data) + parallelBlockSize - 1) / parallelBlockSize
+	members := make([][]byte, blockCount)
+	errs := make([]error, blockCount)
+
+	var wg sync.WaitGroup
+	for i := 0; i < blockCount; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			start := i * parallelBlockSize
+			end := start + parallelBlockSize
+			if end > len(data) {
+				end = len(data)
+			}
+
+			member, err := gzipMember(data[start:end], level)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			members[i] = member
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
 		if err != nil {
-			return err
+			return nil, err
 		}
-		files[n] = buf.Bytes()
 	}
-	return nil
+
+	var out bytes.Buffer
+	for _, member := range members {
+		out.Write(member)
+	}
+	return out.Bytes(), nil
+}
+
+// gzipMember encodes block as a single, self-contained gzip member: a
+// fixed minimal header, the block deflated on its own, and a
+// CRC32/ISIZE footer computed over block.
+func gzipMember(block []byte, level int) ([]byte, error) {
+	var out bytes.Buffer
+	out.Write([]byte{0x1f, 0x8b, 8, 0, 0, 0, 0, 0, 0, 0xff})
+
+	fw, err := flate.NewWriter(&out, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fw.Write(block); err != nil {
+		return nil, err
+	}
+	if err := fw.Close(); err != nil {
+		return nil, err
+	}
+
+	var footer [8]byte
+	binary.LittleEndian.PutUint32(footer[0:4], crc32.ChecksumIEEE(block))
+	binary.LittleEndian.PutUint32(footer[4:8], uint32(len(block)))
+	out.Write(footer[:])
+
+	return out.Bytes(), nil
 }
 
 func isModified(file string, orig []byte) (bool, error) {
@@ -284,36 +693,62 @@ func isModified(file string, orig []byte) (bool, error) {
 	return true, nil
 }
 
-func loadFiles(inputs []input, ignore []*regexp.Regexp) (names []string, files map[string][]byte, err error) {
+// keyFor applies the alias/strip prefix syntax described in the Inputs
+// section of Usage to src, which may be a path on disk or a path inside
+// an archive.
+func keyFor(base input, src string) (string, error) {
+	src = filepath.ToSlash(src)
+
+	key := strings.TrimLeft(src, "/")
+	parts := strings.Split(key, "/")
+	if len(parts) < base.Strip {
+		return "", fmt.Errorf("path shorter than strip")
+	}
+	parts = parts[base.Strip:]
+	key = strings.Join(parts, "/")
+
+	if base.Alias != "" {
+		key = base.Alias + "/" + key
+	}
+
+	return key, nil
+}
+
+// pendingFile is a disk file that passed the ignore patterns and has
+// had its output key resolved, queued up to be read by readPending.
+type pendingFile struct {
+	key  string
+	path string
+}
+
+func loadFiles(inputs []input, ignore []*regexp.Regexp, jobs int) (names []string, files map[string][]byte, err error) {
 	files = make(map[string][]byte)
 	names = make([]string, 0)
 
-	addFile := func(base input, src string, isDir bool) error {
+	var pending []pendingFile
+
+	addPending := func(base input, src string) error {
 		for _, ig := range ignore {
 			if ig.MatchString(src) {
 				return nil
 			}
 		}
 
-		src = filepath.ToSlash(src)
-
-		key := strings.TrimLeft(src, "/")
-		parts := strings.Split(key, "/")
-		if len(parts) < base.Strip {
-			return fmt.Errorf("path shorter than strip")
+		key, err := keyFor(base, src)
+		if err != nil {
+			return err
 		}
-		parts = parts[base.Strip:]
-		key = strings.Join(parts, "/")
 
-		if base.Alias != "" {
-			key = base.Alias + "/" + key
+		if _, exists := files[key]; exists {
+			return fmt.Errorf("key %q was produced more than once", key)
 		}
 
-		files[key], err = ioutil.ReadFile(filepath.FromSlash(src))
-		if err != nil {
-			return err
-		}
-		names = append(names, key)
+		// Reserve the key immediately so a later disk file or archive
+		// entry producing the same key is caught here rather than
+		// silently overwriting it once readPending's results land; the
+		// real data replaces this nil placeholder below.
+		files[key] = nil
+		pending = append(pending, pendingFile{key: key, path: src})
 		return nil
 	}
 
@@ -330,7 +765,7 @@ func loadFiles(inputs []input, ignore []*regexp.Regexp) (names []string, files m
 					return err
 				}
 				if !info.IsDir() {
-					if err = addFile(src, path, true); err != nil {
+					if err = addPending(src, path); err != nil {
 						return err
 					}
 				}
@@ -340,15 +775,265 @@ func loadFiles(inputs []input, ignore []*regexp.Regexp) (names []string, files m
 				return nil, nil, err
 			}
 
-		} else if err := addFile(src, src.Path, false); err != nil {
+			continue
+		}
+
+		kind, err := detectArchiveAt(src.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if kind == archiveNone {
+			if err := addPending(src, src.Path); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		raw, err := ioutil.ReadFile(src.Path)
+		if err != nil {
 			return nil, nil, err
 		}
+		if err := loadArchive(kind, raw, src, files, &names, ignore); err != nil {
+			return nil, nil, fmt.Errorf("bingen: %s: %v", src.Path, err)
+		}
+	}
+
+	read, err := readPending(pending, jobs)
+	if err != nil {
+		return nil, nil, err
+	}
+	for key, data := range read {
+		files[key] = data
+		names = append(names, key)
 	}
 
 	sort.Strings(names)
 	return
 }
 
+// readPending reads every pending file's contents from disk across a
+// pool of jobs workers, so that walking a large input tree isn't
+// bottlenecked on one file's I/O at a time.
+func readPending(pending []pendingFile, jobs int) (map[string][]byte, error) {
+	type result struct {
+		key  string
+		data []byte
+		err  error
+	}
+
+	work := make(chan pendingFile)
+	results := make(chan result)
+
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range work {
+				data, err := ioutil.ReadFile(filepath.FromSlash(p.path))
+				results <- result{key: p.key, data: data, err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	go func() {
+		defer close(work)
+		for _, p := range pending {
+			work <- p
+		}
+	}()
+
+	out := make(map[string][]byte, len(pending))
+	var firstErr error
+	for r := range results {
+		if r.err != nil {
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			continue
+		}
+		out[r.key] = r.data
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return out, nil
+}
+
+// archiveKind identifies one of the archive formats bingen can unpack
+// in memory.
+type archiveKind int
+
+const (
+	archiveNone archiveKind = iota
+	archiveZip
+	archiveTar
+	archiveTarGz
+	archiveTarBz2
+)
+
+// detectArchiveAt identifies the archive format of path, first by
+// extension and, failing that, by sniffing the file's magic bytes. It
+// returns archiveNone if path doesn't look like a supported archive.
+func detectArchiveAt(path string) (archiveKind, error) {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return archiveZip, nil
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return archiveTarGz, nil
+	case strings.HasSuffix(lower, ".tar.bz2"), strings.HasSuffix(lower, ".tbz2"):
+		return archiveTarBz2, nil
+	case strings.HasSuffix(lower, ".tar"):
+		return archiveTar, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return archiveNone, err
+	}
+	defer f.Close()
+
+	var head [262]byte
+	n, err := io.ReadFull(f, head[:])
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return archiveNone, err
+	}
+
+	return archiveKindFromMagic(head[:n]), nil
+}
+
+func archiveKindFromMagic(head []byte) archiveKind {
+	switch {
+	case len(head) >= 4 && bytes.Equal(head[:4], []byte("PK\x03\x04")):
+		return archiveZip
+	case len(head) >= 2 && head[0] == 0x1f && head[1] == 0x8b:
+		return archiveTarGz
+	case len(head) >= 3 && string(head[:3]) == "BZh":
+		return archiveTarBz2
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return archiveTar
+	}
+	return archiveNone
+}
+
+// loadArchive unpacks raw, an archive of the given kind, adding each of
+// its entries to files/names as though it were a file on disk rooted at
+// base.
+func loadArchive(kind archiveKind, raw []byte, base input, files map[string][]byte, names *[]string, ignore []*regexp.Regexp) error {
+	switch kind {
+	case archiveZip:
+		return loadZip(raw, base, files, names, ignore)
+	case archiveTar:
+		return loadTar(bytes.NewReader(raw), base, files, names, ignore)
+	case archiveTarGz:
+		gr, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return fmt.Errorf("could not read gzip archive: %v", err)
+		}
+		defer gr.Close()
+		return loadTar(gr, base, files, names, ignore)
+	case archiveTarBz2:
+		return loadTar(bzip2.NewReader(bytes.NewReader(raw)), base, files, names, ignore)
+	default:
+		return fmt.Errorf("unknown archive kind %d", kind)
+	}
+}
+
+func loadZip(raw []byte, base input, files map[string][]byte, names *[]string, ignore []*regexp.Regexp) error {
+	zr, err := zip.NewReader(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		return fmt.Errorf("could not read zip archive: %v", err)
+	}
+
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() {
+			continue
+		}
+
+		rc, err := zf.Open()
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+
+		if err := addArchiveFile(files, names, base, zf.Name, data, ignore); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func loadTar(r io.Reader, base input, files map[string][]byte, names *[]string, ignore []*regexp.Regexp) error {
+	tr := tar.NewReader(r)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("could not read tar archive: %v", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+
+		if err := addArchiveFile(files, names, base, hdr.Name, data, ignore); err != nil {
+			return err
+		}
+	}
+}
+
+// addArchiveFile applies the ignore patterns and alias/strip prefix to
+// an archive-internal path, rejects path traversal, and adds the file
+// to files/names, erroring if the resulting key already exists.
+func addArchiveFile(files map[string][]byte, names *[]string, base input, entryPath string, data []byte, ignore []*regexp.Regexp) error {
+	entryPath = filepath.ToSlash(entryPath)
+
+	if filepath.IsAbs(entryPath) {
+		return fmt.Errorf("archive entry %q is an absolute path", entryPath)
+	}
+	for _, part := range strings.Split(entryPath, "/") {
+		if part == ".." {
+			return fmt.Errorf("archive entry %q attempts path traversal", entryPath)
+		}
+	}
+
+	for _, ig := range ignore {
+		if ig.MatchString(entryPath) {
+			return nil
+		}
+	}
+
+	key, err := keyFor(base, entryPath)
+	if err != nil {
+		return err
+	}
+
+	if _, exists := files[key]; exists {
+		return fmt.Errorf("key %q was produced more than once", key)
+	}
+
+	files[key] = data
+	*names = append(*names, key)
+	return nil
+}
+
 type input struct {
 	Alias string
 	Strip int
@@ -422,8 +1107,11 @@ func (s *stringList) Set(v string) error {
 type binMapVars struct {
 	Package  string
 	Name     string
+	FSVar    string
 	Tags     string
 	Map      string
+	ETags    string
+	Codec    string
 	Deflated bool
 	AsConfig bool
 	Mode     string
@@ -432,20 +1120,30 @@ type binMapVars struct {
 var binMapTpl = `
 // Code generated by 'github.com/shabbyrobe/go-bingen'. DO NOT EDIT.
 
-{{ if .Deflated }}// File data is compressed! See compress/gzip.{{ end }}
+{{ if .Deflated }}// File data is compressed with the "{{.Codec}}" codec. See binfs.Codec.{{ end }}
 
 {{ if .Tags }}// +build {{.Tags}}{{ end }}
 
 package {{.Package}}
 
 {{ if .AsConfig }}
-import "github.com/shabbyrobe/go-bingen/binfs"
+import (
+	"github.com/shabbyrobe/go-bingen/binfs"
+	{{ if .FSVar }}"io/fs"{{ end }}
+)
 
 var {{.Name}} = binfs.Config{
-	Gzip: {{ if .Deflated }}true{{ else }}false{{ end }},
-	Mode: {{printf "%q" .Mode}},
-	Data: {{.Map -}},
+	Codec: {{printf "%q" .Codec}},
+	Mode:  {{printf "%q" .Mode}},
+	Data:  {{.Map -}},
+	ETags: {{.ETags -}},
 }
+
+{{ if .FSVar }}
+// {{.FSVar}} is an io/fs.FS view of {{.Name}}, suitable for
+// html/template.ParseFS, text/template.ParseFS or http.FS.
+var {{.FSVar}} fs.FS = binfs.FS({{.Name}}.New())
+{{ end }}
 {{ else }}
 var {{.Name}} = {{.Map}}
 {{ end }}
@@ -0,0 +1,93 @@
+package bingen
+
+import (
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// TestCompressGzipParallelBlocksRoundTrip guards against the output of
+// compressGzipParallelBlocks failing to decompress: every size here
+// that spans more than one block at one point produced gzip members
+// whose back-references pointed into a previous block's "virtual"
+// dictionary, which a plain gzip.Reader can't resolve.
+func TestCompressGzipParallelBlocksRoundTrip(t *testing.T) {
+	sizes := []int{
+		1,
+		parallelBlockSize - 1,
+		parallelBlockSize,
+		parallelBlockSize + 1,
+		parallelBlockSize*2 + 12345,
+		parallelBlockSize*3 + 1,
+	}
+
+	for _, size := range sizes {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i * 7 % 251)
+		}
+
+		compressed, err := compressGzipParallelBlocks(data, gzip.DefaultCompression)
+		if err != nil {
+			t.Fatalf("size %d: compress: %v", size, err)
+		}
+
+		gr, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			t.Fatalf("size %d: new reader: %v", size, err)
+		}
+		got, err := ioutil.ReadAll(gr)
+		if err != nil {
+			t.Fatalf("size %d: read: %v", size, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("size %d: round-trip produced different data", size)
+		}
+	}
+}
+
+// TestCommandRunParallel exercises the full Run pipeline - reading,
+// compressing and encoding - over enough files at a high enough -jobs
+// value to actually contend on the files map in compressFiles, which is
+// what the concurrent map write fatal only showed up under.
+func TestCommandRunParallel(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 64; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("file%02d.txt", i))
+		data := bytes.Repeat([]byte{byte('a' + i%26)}, 4096)
+		if err := ioutil.WriteFile(name, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := filepath.Join(t.TempDir(), "generated.go")
+
+	var cmd Command
+	fs := flag.NewFlagSet("bingen", flag.ContinueOnError)
+	cmd.Flags(fs)
+	if err := fs.Parse([]string{
+		"-pkg", "testpkg",
+		"-out", out,
+		"-jobs", "8",
+		"-codec", "gzip:9",
+		dir,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cmd.Run(fs.Args()...); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	generated, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatalf("reading generated file: %v", err)
+	}
+	if !bytes.Contains(generated, []byte("binfs.Config{")) {
+		t.Fatalf("generated file doesn't look like a binfs.Config:\n%s", generated)
+	}
+}